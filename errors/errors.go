@@ -0,0 +1,97 @@
+// Package errors defines the typed error value returned by the
+// evaluator, so that callers - including our own tests - can
+// distinguish a failure's category without depending on the exact
+// wording of its message.
+package errors
+
+import "fmt"
+
+// Code identifies the category of a BasicError.
+type Code int
+
+// The error categories the evaluator can produce.
+const (
+	// ErrTypeMismatch covers an operation applied to operands of
+	// incompatible, or individually unsupported, types.
+	ErrTypeMismatch Code = iota
+
+	// ErrUnclosedBracket covers a '(' never matched by a ')'.
+	ErrUnclosedBracket
+
+	// ErrEndOfProgram covers the program being exhausted while a
+	// statement was still expecting more tokens.
+	ErrEndOfProgram
+
+	// ErrReadPastEnd covers a READ that consumes more values than
+	// DATA provided.
+	ErrReadPastEnd
+
+	// ErrUndefinedIdent covers a variable, function, or routine
+	// referenced before it has been set or defined, and a GOTO, GOSUB
+	// or RESTORE target line that does not exist.
+	ErrUndefinedIdent
+
+	// ErrArity covers a function or routine called with the wrong
+	// number of arguments.
+	ErrArity
+
+	// ErrRuntime covers a failure that only manifests while a program
+	// is running - division by zero, exceeding the call-depth limit,
+	// a SUB falling off its end without a RETURN, or a host Callable
+	// rejecting a call.
+	ErrRuntime
+
+	// ErrSyntax covers any other malformed statement.
+	ErrSyntax
+)
+
+// Location records where, in the source, an error occurred.
+type Location struct {
+	// Line is the BASIC line-number of the statement in error - 0 if
+	// the program never assigned one.
+	Line int
+
+	// Column is the 1-based character column, within its source
+	// line, of the token the error was raised at.
+	Column int
+}
+
+// BasicError is the concrete type behind every error the evaluator
+// returns.
+type BasicError struct {
+	// Code identifies the category of the error.
+	Code Code
+
+	// Location records where in the source it occurred.
+	Location Location
+
+	// Message is the human-readable description of the error.
+	Message string
+}
+
+// New creates a BasicError of the given code and location, with a
+// printf-formatted message.
+func New(code Code, loc Location, format string, args ...interface{}) *BasicError {
+	return &BasicError{Code: code, Location: loc, Message: fmt.Sprintf(format, args...)}
+}
+
+// Error implements the error interface. The wording - "line N: ..." -
+// is unchanged from before BasicError existed, so code that still does
+// strings.Contains(err.Error(), "...") keeps working.
+func (b *BasicError) Error() string {
+	if b.Location.Line != 0 {
+		return fmt.Sprintf("line %d: %s", b.Location.Line, b.Message)
+	}
+	return b.Message
+}
+
+// Is reports whether err is a *BasicError of the given Code. It lets
+// callers write errors.Is(err, errors.ErrTypeMismatch) instead of
+// matching against the wording of Error().
+func Is(err error, code Code) bool {
+	b, ok := err.(*BasicError)
+	if !ok {
+		return false
+	}
+	return b.Code == code
+}
@@ -0,0 +1,116 @@
+// callable.go - Support for registering host (Go) functions so that an
+// embedding program can expose domain-specific functions to BASIC code
+// without forking the interpreter.
+package eval
+
+import (
+	"github.com/skx/gobasic/errors"
+	"github.com/skx/gobasic/object"
+	"github.com/skx/gobasic/token"
+)
+
+// Callable is implemented by any Go function an embedding program
+// wishes to make available to BASIC scripts as `NAME(args...)`.
+type Callable interface {
+	// Name is the identifier BASIC code uses to invoke this function.
+	Name() string
+
+	// Params describes, in order, the type each argument must have.
+	Params() []object.ObjectType
+
+	// Ret describes the type of the value Call returns.
+	Ret() object.ObjectType
+
+	// Call invokes the function with arguments that have already
+	// been arity- and type-checked against Params(). Returning an
+	// object.Error is the way to signal a runtime failure back to
+	// the calling BASIC program.
+	Call(e *Interpreter, args []object.Object) object.Object
+}
+
+// Register adds c to this interpreter's registry of host-provided
+// functions, so that it becomes callable from BASIC as `c.Name()(...)`.
+// Registering a function under a name that's already registered
+// replaces the previous entry. The registry belongs to a single
+// Interpreter, so multiple interpreters may register different
+// functions under the same name without conflict.
+func (e *Interpreter) Register(c Callable) {
+	if e.callables == nil {
+		e.callables = make(map[string]Callable)
+	}
+	e.callables[c.Name()] = c
+}
+
+// DeregisterAll removes every function previously added via Register.
+func (e *Interpreter) DeregisterAll() {
+	e.callables = nil
+}
+
+// parseCallArgs parses a parenthesized, comma-separated argument list,
+// whose opening '(' is the current token. It is shared by calls to
+// host-registered Callables and to BASIC-defined DEF FN/SUB routines.
+func (e *Interpreter) parseCallArgs() ([]object.Object, error) {
+	e.offset++ // consume '('
+
+	var args []object.Object
+	if e.current().Type != token.RPAREN {
+		for {
+			if e.current().Type == token.EOF {
+				return nil, e.eofErr()
+			}
+			arg, err := e.expr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if e.current().Type == token.COMMA {
+				e.offset++
+				continue
+			}
+			break
+		}
+	}
+
+	if e.current().Type == token.EOF {
+		return nil, e.eofErr()
+	}
+	if e.current().Type != token.RPAREN {
+		return nil, e.err(errors.ErrSyntax, "expected ')' after argument list, got %s", e.current().Type)
+	}
+	e.offset++
+
+	return args, nil
+}
+
+// callHostFunction parses and evaluates a call to a host-registered
+// Callable, whose name has already been consumed and whose next token
+// is the opening '(' of its argument list.
+func (e *Interpreter) callHostFunction(name string, c Callable) (object.Object, error) {
+	args, err := e.parseCallArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	params := c.Params()
+	if len(args) != len(params) {
+		return nil, e.err(errors.ErrArity, "%s() expects %d argument(s), got %d", name, len(params), len(args))
+	}
+	for i, arg := range args {
+		if arg.Type() != params[i] {
+			return nil, e.err(errors.ErrTypeMismatch, "%s() argument %d: expected %s, got %s", name, i+1, params[i], arg.Type())
+		}
+	}
+
+	result := c.Call(e, args)
+	if result == nil {
+		return nil, e.err(errors.ErrRuntime, "%s() returned no value", name)
+	}
+	if result.Type() == object.ERROR {
+		return nil, e.err(errors.ErrRuntime, "%s", result.String())
+	}
+	if result.Type() != c.Ret() {
+		return nil, e.err(errors.ErrTypeMismatch, "%s() returned %s, expected %s", name, result.Type(), c.Ret())
+	}
+	return result, nil
+}
@@ -0,0 +1,986 @@
+// eval.go - Our evaluator, which walks the token-stream produced by the
+// tokenizer and executes the BASIC program it represents.
+package eval
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/skx/gobasic/errors"
+	"github.com/skx/gobasic/object"
+	"github.com/skx/gobasic/token"
+	"github.com/skx/gobasic/tokenizer"
+)
+
+// forLoop records the state of a single active FOR/NEXT loop.
+type forLoop struct {
+	// name is the loop-variable.
+	name string
+
+	// limit is the value the loop-variable must not exceed (or fall
+	// below, for a negative step).
+	limit float64
+
+	// step is the amount the loop-variable changes by on each NEXT.
+	step float64
+
+	// bodyOffset is the token-offset of the first statement inside
+	// the loop, i.e. where NEXT jumps back to.
+	bodyOffset int
+}
+
+// Interpreter holds our state, and is responsible for running a BASIC
+// program which has already been tokenized.
+type Interpreter struct {
+	// tokens holds every token in the program, in order.
+	tokens []token.Token
+
+	// offset is the index of the next token to be processed.
+	offset int
+
+	// curLine is the BASIC line-number of the statement currently
+	// being executed, used for error-reporting.
+	curLine int
+
+	// lines maps a BASIC line-number to the token-offset of the
+	// first token of the statement it introduces, for GOTO/GOSUB.
+	lines map[int]int
+
+	// vars holds the value of every variable set via LET/READ/INPUT,
+	// or via SetVariable.
+	vars map[string]object.Object
+
+	// data holds every value collected from DATA statements, in the
+	// order they appear in the source.
+	data []object.Object
+
+	// dataOffset is the index of the next value READ will consume.
+	dataOffset int
+
+	// dataLines maps a BASIC line-number holding a DATA statement to
+	// the index, within e.data, of the first value it contributed -
+	// so that RESTORE <lineno> knows where to rewind the read cursor
+	// to. See collectData and RestoreData.
+	dataLines map[int]int
+
+	// forLoops is the stack of currently active FOR loops.
+	forLoops []forLoop
+
+	// gosubStack holds the return-offsets for pending GOSUB calls.
+	gosubStack []int
+
+	// trace records whether tracing is enabled.
+	trace bool
+
+	// callables holds the host-registered functions available to
+	// this interpreter, keyed by name. See Register.
+	callables map[string]Callable
+
+	// userFuncs holds the BASIC-defined DEF FN/SUB routines found in
+	// the program, keyed by name. See userfunc.go.
+	userFuncs map[string]*userFunc
+
+	// callDepth is the number of DEF FN/SUB calls currently in
+	// progress, used to bound recursion and to decide what RETURN
+	// means. See userfunc.go.
+	callDepth int
+
+	// gosubBase records, for each active DEF FN/SUB call, the length
+	// gosubStack had when the call began - so that RETURN can tell a
+	// GOSUB issued from within the call's own body (which it must pop)
+	// from the RETURN that ends the call itself. See userfunc.go.
+	gosubBase []int
+}
+
+// New creates a new Interpreter from the given tokenizer, consuming it
+// entirely and performing the handful of up-front passes (DATA
+// collection, DEF FN/SUB collection, line-number indexing) that the
+// rest of the evaluator relies on.
+func New(t *tokenizer.Tokenizer) (*Interpreter, error) {
+	e := &Interpreter{vars: make(map[string]object.Object), userFuncs: make(map[string]*userFunc)}
+
+	for {
+		tok := t.Next()
+		e.tokens = append(e.tokens, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	if err := e.collectData(); err != nil {
+		return nil, err
+	}
+	if err := e.collectUserFuncs(); err != nil {
+		return nil, err
+	}
+	e.indexLines()
+
+	return e, nil
+}
+
+// FromString is a convenience wrapper which tokenizes the given program
+// text and returns a ready-to-run Interpreter.
+func FromString(input string) (*Interpreter, error) {
+	return New(tokenizer.New(input))
+}
+
+// GetTrace returns whether tracing of statement-execution is enabled.
+func (e *Interpreter) GetTrace() bool {
+	return e.trace
+}
+
+// SetTrace enables, or disables, tracing of statement-execution.
+func (e *Interpreter) SetTrace(enabled bool) {
+	e.trace = enabled
+}
+
+// GetVariable returns the value of the named variable, or an
+// object.ErrorObject if it has never been set.
+func (e *Interpreter) GetVariable(name string) object.Object {
+	if val, ok := e.vars[name]; ok {
+		return val
+	}
+	return object.Error("the variable '%s' is not set", name)
+}
+
+// SetVariable sets the value of the named variable, creating it if
+// required.
+func (e *Interpreter) SetVariable(name string, val object.Object) {
+	e.vars[name] = val
+}
+
+// RestoreData rewinds the DATA read-cursor to the first value
+// collected from the DATA statement on the given BASIC line, so that a
+// following READ consumes it again. It returns an error if line holds
+// no DATA statement. It is the Go-callable equivalent of the RESTORE
+// <lineno> statement, for host programs driving an Interpreter directly.
+func (e *Interpreter) RestoreData(line int) error {
+	idx, ok := e.dataLines[line]
+	if !ok {
+		return e.err(errors.ErrUndefinedIdent, "RESTORE target %d does not exist", line)
+	}
+	e.dataOffset = idx
+	return nil
+}
+
+// collectData walks every token in the program looking for DATA
+// statements, and populates e.data with the values they contain,
+// indexing each DATA statement by the BASIC line-number it appears on
+// so that RESTORE <lineno> can rewind the read cursor to it. It is run
+// once, up-front, so that READ can consume values regardless of the
+// order in which DATA statements are executed - and so that a
+// malformed DATA statement is reported before the program ever runs.
+func (e *Interpreter) collectData() error {
+	e.dataLines = make(map[int]int)
+
+	line := 0
+	atLineStart := true
+	i := 0
+	for i < len(e.tokens) {
+		tok := e.tokens[i]
+
+		switch {
+		case tok.Type == token.NEWLINE:
+			atLineStart = true
+			i++
+		case atLineStart && tok.Type == token.INT:
+			if n, err := strconv.Atoi(tok.Value); err == nil {
+				line = n
+			}
+			atLineStart = false
+			i++
+		case tok.Type == token.DATA:
+			atLineStart = false
+			e.dataLines[line] = len(e.data)
+			i++
+			for i < len(e.tokens) && e.tokens[i].Type != token.NEWLINE && e.tokens[i].Type != token.EOF {
+				item := e.tokens[i]
+				switch item.Type {
+				case token.COMMA:
+					// just a separator
+				case token.INT:
+					val, _ := strconv.ParseFloat(item.Value, 64)
+					e.data = append(e.data, object.Number(val))
+				case token.STRING:
+					e.data = append(e.data, object.String(item.Value))
+				default:
+					return errors.New(errors.ErrSyntax, errors.Location{Line: line, Column: item.Location.Column}, "invalid DATA item %q: expected a number or a string", item.Value)
+				}
+				i++
+			}
+		default:
+			atLineStart = false
+			i++
+		}
+	}
+	return nil
+}
+
+// indexLines records, for every line-number which appears at the start
+// of a line, the token-offset of the statement it introduces - so that
+// GOTO and GOSUB can jump directly to it.
+func (e *Interpreter) indexLines() {
+	e.lines = make(map[int]int)
+
+	atLineStart := true
+	for i := 0; i < len(e.tokens); i++ {
+		switch {
+		case e.tokens[i].Type == token.NEWLINE:
+			atLineStart = true
+		case atLineStart && e.tokens[i].Type == token.INT:
+			if n, err := strconv.Atoi(e.tokens[i].Value); err == nil {
+				e.lines[n] = i + 1
+			}
+			atLineStart = false
+		default:
+			atLineStart = false
+		}
+	}
+}
+
+// current returns the token at the current offset, or an EOF token
+// once the program has been exhausted.
+func (e *Interpreter) current() token.Token {
+	if e.offset >= len(e.tokens) {
+		return token.Token{Type: token.EOF}
+	}
+	return e.tokens[e.offset]
+}
+
+// err builds a typed, located error for the statement currently being
+// executed - the line comes from e.curLine, the column from whichever
+// token is current when it's called.
+func (e *Interpreter) err(code errors.Code, format string, args ...interface{}) error {
+	return errors.New(code, errors.Location{Line: e.curLine, Column: e.current().Location.Column}, format, args...)
+}
+
+// eofErr reports that the program ended while a statement was still
+// expecting more tokens.
+func (e *Interpreter) eofErr() error {
+	return e.err(errors.ErrEndOfProgram, "unexpected end of program")
+}
+
+// skipToNewline discards tokens, without interpreting them, until the
+// end of the current statement.
+func (e *Interpreter) skipToNewline() {
+	for e.current().Type != token.NEWLINE && e.current().Type != token.EOF {
+		e.offset++
+	}
+}
+
+// Run executes the program, from the beginning, until it falls off the
+// end or a statement produces an error.
+func (e *Interpreter) Run() error {
+	for {
+		tok := e.current()
+
+		switch tok.Type {
+		case token.EOF:
+			return nil
+		case token.NEWLINE:
+			e.offset++
+		case token.INT:
+			// A line-number, introducing the next statement.
+			if n, err := strconv.Atoi(tok.Value); err == nil {
+				e.curLine = n
+			}
+			e.offset++
+		default:
+			if err := e.runStatement(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// runStatement executes a single statement, starting at the current
+// offset.
+func (e *Interpreter) runStatement() error {
+	switch e.current().Type {
+	case token.LET:
+		return e.runLET()
+	case token.PRINT:
+		return e.runPRINT()
+	case token.IF:
+		return e.runIF()
+	case token.FOR:
+		return e.runFOR()
+	case token.NEXT:
+		return e.runNEXT()
+	case token.GOTO:
+		return e.runGOTO()
+	case token.GOSUB:
+		return e.runGOSUB()
+	case token.RETURN:
+		return e.runRETURN()
+	case token.DATA:
+		e.skipToNewline()
+		return nil
+	case token.READ:
+		return e.runREAD()
+	case token.RESTORE:
+		return e.runRESTORE()
+	case token.INPUT:
+		return e.runINPUT()
+	case token.REM:
+		e.skipToNewline()
+		return nil
+	case token.DEF:
+		// Already parsed by collectUserFuncs; skip over its body.
+		e.skipToNewline()
+		return nil
+	case token.SUB:
+		// Already parsed by collectUserFuncs; skip over its body.
+		e.skipSub()
+		return nil
+	default:
+		return e.err(errors.ErrSyntax, "unexpected token %s, expected a statement", e.current().Type)
+	}
+}
+
+// skipStatement discards the tokens of a single statement, without
+// interpreting them - used to skip the untaken branch of an IF.
+func (e *Interpreter) skipStatement() {
+	for {
+		switch e.current().Type {
+		case token.NEWLINE, token.EOF, token.ELSE:
+			return
+		default:
+			e.offset++
+		}
+	}
+}
+
+// runLET handles `LET var = expr`.
+func (e *Interpreter) runLET() error {
+	e.offset++
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	if e.current().Type != token.IDENT {
+		return e.err(errors.ErrSyntax, "expected an identifier after LET, got %s", e.current().Type)
+	}
+	name := e.current().Value
+	e.offset++
+
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	if e.current().Type != token.ASSIGN {
+		return e.err(errors.ErrSyntax, "expected '=' after LET %s, got %s", name, e.current().Type)
+	}
+	e.offset++
+
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	val, err := e.expr()
+	if err != nil {
+		return err
+	}
+
+	e.vars[name] = val
+	return nil
+}
+
+// canStartExpr reports whether the given token-type may begin an
+// expression - used by PRINT to decide whether two adjacent items are
+// a continuation of the print-list, even without an explicit comma.
+func canStartExpr(t token.Type) bool {
+	switch t {
+	case token.INT, token.STRING, token.IDENT, token.LPAREN, token.MINUS:
+		return true
+	default:
+		return false
+	}
+}
+
+// runPRINT handles `PRINT expr [, expr ...]`.
+func (e *Interpreter) runPRINT() error {
+	e.offset++
+
+	for {
+		if e.current().Type == token.EOF {
+			return e.eofErr()
+		}
+		val, err := e.expr()
+		if err != nil {
+			return err
+		}
+		fmt.Print(val.String())
+
+		if e.current().Type == token.COMMA {
+			e.offset++
+			continue
+		}
+		if canStartExpr(e.current().Type) {
+			continue
+		}
+		break
+	}
+	return nil
+}
+
+// isComparison reports whether the given token-type is a comparison
+// operator, as used by IF.
+func isComparison(t token.Type) bool {
+	switch t {
+	case token.LT, token.GT, token.LE, token.GE, token.ASSIGN, token.EQ, token.NE:
+		return true
+	default:
+		return false
+	}
+}
+
+// compare evaluates `left op right`, where left and right are of the
+// same type.
+func compare(left object.Object, op token.Type, right object.Object, line int) (bool, error) {
+	if left.Type() != right.Type() {
+		return false, errors.New(errors.ErrTypeMismatch, errors.Location{Line: line}, "type mismatch in comparison: %s %s %s", left.Type(), op, right.Type())
+	}
+
+	switch left.Type() {
+	case object.NUMBER:
+		l := left.(*object.NumberObject).Value
+		r := right.(*object.NumberObject).Value
+		switch op {
+		case token.LT:
+			return l < r, nil
+		case token.GT:
+			return l > r, nil
+		case token.LE:
+			return l <= r, nil
+		case token.GE:
+			return l >= r, nil
+		case token.ASSIGN, token.EQ:
+			return l == r, nil
+		case token.NE:
+			return l != r, nil
+		}
+	case object.STRING:
+		l := left.(*object.StringObject).Value
+		r := right.(*object.StringObject).Value
+		switch op {
+		case token.LT:
+			return l < r, nil
+		case token.GT:
+			return l > r, nil
+		case token.LE:
+			return l <= r, nil
+		case token.GE:
+			return l >= r, nil
+		case token.ASSIGN, token.EQ:
+			return l == r, nil
+		case token.NE:
+			return l != r, nil
+		}
+	}
+	return false, errors.New(errors.ErrTypeMismatch, errors.Location{Line: line}, "unsupported comparison of %s", left.Type())
+}
+
+// truthy reports whether the given object counts as "true" when used
+// as the sole condition of an IF - a non-zero number, or a non-empty
+// string.
+func truthy(o object.Object) bool {
+	switch v := o.(type) {
+	case *object.NumberObject:
+		return v.Value != 0
+	case *object.StringObject:
+		return v.Value != ""
+	default:
+		return false
+	}
+}
+
+// runIF handles `IF cond THEN stmt [ELSE stmt]`, where cond is either a
+// comparison of two expressions, or a single expression tested for
+// truthiness.
+func (e *Interpreter) runIF() error {
+	e.offset++
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+
+	left, err := e.expr()
+	if err != nil {
+		return err
+	}
+
+	result := false
+	if isComparison(e.current().Type) {
+		op := e.current().Type
+		e.offset++
+		if e.current().Type == token.EOF {
+			return e.eofErr()
+		}
+		right, err := e.expr()
+		if err != nil {
+			return err
+		}
+		result, err = compare(left, op, right, e.curLine)
+		if err != nil {
+			return err
+		}
+	} else {
+		result = truthy(left)
+	}
+
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	if e.current().Type != token.THEN {
+		return e.err(errors.ErrSyntax, "expected THEN, got %s", e.current().Type)
+	}
+	e.offset++
+
+	if result {
+		if err := e.runStatement(); err != nil {
+			return err
+		}
+		if e.current().Type == token.ELSE {
+			e.offset++
+			e.skipStatement()
+		}
+		return nil
+	}
+
+	e.skipStatement()
+	if e.current().Type == token.ELSE {
+		e.offset++
+		return e.runStatement()
+	}
+	return nil
+}
+
+// runFOR handles `FOR var = start TO limit [STEP step]`.
+func (e *Interpreter) runFOR() error {
+	e.offset++
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	if e.current().Type != token.IDENT {
+		return e.err(errors.ErrSyntax, "expected an identifier after FOR, got %s", e.current().Type)
+	}
+	name := e.current().Value
+	e.offset++
+
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	if e.current().Type != token.ASSIGN {
+		return e.err(errors.ErrSyntax, "expected '=' after FOR %s, got %s", name, e.current().Type)
+	}
+	e.offset++
+
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	start, err := e.expr()
+	if err != nil {
+		return err
+	}
+	if start.Type() != object.NUMBER {
+		return e.err(errors.ErrTypeMismatch, "FOR start-value must be a number, got %s", start.Type())
+	}
+
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	if e.current().Type != token.TO {
+		return e.err(errors.ErrSyntax, "expected TO in FOR, got %s", e.current().Type)
+	}
+	e.offset++
+
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	limit, err := e.expr()
+	if err != nil {
+		return err
+	}
+	if limit.Type() != object.NUMBER {
+		return e.err(errors.ErrTypeMismatch, "FOR limit-value must be a number, got %s", limit.Type())
+	}
+
+	step := 1.0
+	if e.current().Type == token.STEP {
+		e.offset++
+		if e.current().Type == token.EOF {
+			return e.eofErr()
+		}
+		stepVal, err := e.expr()
+		if err != nil {
+			return err
+		}
+		if stepVal.Type() != object.NUMBER {
+			return e.err(errors.ErrTypeMismatch, "FOR step-value must be a number, got %s", stepVal.Type())
+		}
+		step = stepVal.(*object.NumberObject).Value
+	}
+
+	e.vars[name] = object.Number(start.(*object.NumberObject).Value)
+	e.forLoops = append(e.forLoops, forLoop{name: name, limit: limit.(*object.NumberObject).Value, step: step, bodyOffset: e.offset})
+	return nil
+}
+
+// runNEXT handles `NEXT var`.
+func (e *Interpreter) runNEXT() error {
+	e.offset++
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	if e.current().Type != token.IDENT {
+		return e.err(errors.ErrSyntax, "expected an identifier after NEXT, got %s", e.current().Type)
+	}
+	name := e.current().Value
+	e.offset++
+
+	if len(e.forLoops) == 0 {
+		return e.err(errors.ErrSyntax, "NEXT %s has no matching FOR", name)
+	}
+	loop := e.forLoops[len(e.forLoops)-1]
+	if loop.name != name {
+		return e.err(errors.ErrSyntax, "NEXT %s does not match the innermost FOR %s", name, loop.name)
+	}
+
+	cur := e.vars[name].(*object.NumberObject).Value + loop.step
+	e.vars[name] = object.Number(cur)
+
+	done := cur > loop.limit
+	if loop.step < 0 {
+		done = cur < loop.limit
+	}
+	if done {
+		e.forLoops = e.forLoops[:len(e.forLoops)-1]
+		return nil
+	}
+
+	e.offset = loop.bodyOffset
+	return nil
+}
+
+// runGOTO handles `GOTO line`.
+func (e *Interpreter) runGOTO() error {
+	e.offset++
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	if e.current().Type != token.INT {
+		return e.err(errors.ErrSyntax, "expected a line number after GOTO, got %s", e.current().Type)
+	}
+	n, _ := strconv.Atoi(e.current().Value)
+	e.offset++
+
+	target, ok := e.lines[n]
+	if !ok {
+		return e.err(errors.ErrUndefinedIdent, "GOTO target %d does not exist", n)
+	}
+	e.offset = target
+	return nil
+}
+
+// runGOSUB handles `GOSUB line`.
+func (e *Interpreter) runGOSUB() error {
+	e.offset++
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+	if e.current().Type != token.INT {
+		return e.err(errors.ErrSyntax, "expected a line number after GOSUB, got %s", e.current().Type)
+	}
+	n, _ := strconv.Atoi(e.current().Value)
+	e.offset++
+
+	target, ok := e.lines[n]
+	if !ok {
+		return e.err(errors.ErrUndefinedIdent, "GOSUB target %d does not exist", n)
+	}
+	e.gosubStack = append(e.gosubStack, e.offset)
+	e.offset = target
+	return nil
+}
+
+// runRETURN handles `RETURN`. Inside a DEF FN/SUB call, with no GOSUB of
+// its own still pending, it means "return this value from the call",
+// and unwinds to callUserFunction via the returnSignal sentinel error;
+// otherwise it pops the most recent GOSUB, which may itself have been
+// issued from inside a DEF FN/SUB body.
+func (e *Interpreter) runRETURN() error {
+	e.offset++
+
+	inCallWithNoPendingGosub := e.callDepth > 0 &&
+		len(e.gosubStack) <= e.gosubBase[len(e.gosubBase)-1]
+
+	if inCallWithNoPendingGosub {
+		if e.current().Type == token.EOF {
+			return e.eofErr()
+		}
+		val, err := e.expr()
+		if err != nil {
+			return err
+		}
+		return &returnSignal{value: val}
+	}
+
+	if len(e.gosubStack) == 0 {
+		return e.err(errors.ErrSyntax, "RETURN has no matching GOSUB")
+	}
+	e.offset = e.gosubStack[len(e.gosubStack)-1]
+	e.gosubStack = e.gosubStack[:len(e.gosubStack)-1]
+	return nil
+}
+
+// runRESTORE handles `RESTORE [lineno]`, rewinding the DATA read-cursor
+// either to the very start, or to the DATA statement on the given line.
+func (e *Interpreter) runRESTORE() error {
+	e.offset++
+
+	if e.current().Type != token.INT {
+		e.dataOffset = 0
+		return nil
+	}
+
+	n, _ := strconv.Atoi(e.current().Value)
+	e.offset++
+
+	return e.RestoreData(n)
+}
+
+// runREAD handles `READ var [, var ...]`, pulling values off the
+// front of the DATA collected by collectData.
+func (e *Interpreter) runREAD() error {
+	e.offset++
+
+	readAny := false
+	for {
+		switch e.current().Type {
+		case token.NEWLINE:
+			return nil
+		case token.EOF:
+			// A program may legitimately end with READ as its
+			// last statement and no trailing newline; that's
+			// only an error if we haven't read anything yet.
+			if readAny {
+				return nil
+			}
+			return e.eofErr()
+		case token.COMMA:
+			e.offset++
+		case token.IDENT:
+			name := e.current().Value
+			e.offset++
+			if e.dataOffset >= len(e.data) {
+				return e.err(errors.ErrReadPastEnd, "Read past the end of our DATA storage")
+			}
+			e.vars[name] = e.data[e.dataOffset]
+			e.dataOffset++
+			readAny = true
+		default:
+			return e.err(errors.ErrSyntax, "Expected identifier in READ, got %s", e.current().Type)
+		}
+	}
+}
+
+// runINPUT handles `INPUT ["prompt",] var [, var ...]`.
+func (e *Interpreter) runINPUT() error {
+	e.offset++
+	if e.current().Type == token.EOF {
+		return e.eofErr()
+	}
+
+	if e.current().Type == token.STRING {
+		fmt.Print(e.current().Value)
+		e.offset++
+
+		if e.current().Type == token.EOF {
+			return e.eofErr()
+		}
+		if e.current().Type != token.COMMA {
+			return e.err(errors.ErrSyntax, "expected ',' after INPUT prompt, got %s", e.current().Type)
+		}
+		e.offset++
+		if e.current().Type == token.EOF {
+			return e.eofErr()
+		}
+	}
+
+	for {
+		if e.current().Type != token.IDENT {
+			return e.err(errors.ErrSyntax, "expected an identifier in INPUT, got %s", e.current().Type)
+		}
+		name := e.current().Value
+		e.offset++
+
+		var line string
+		fmt.Scanln(&line)
+		if n, err := strconv.ParseFloat(line, 64); err == nil {
+			e.vars[name] = object.Number(n)
+		} else {
+			e.vars[name] = object.String(line)
+		}
+
+		if e.current().Type == token.COMMA {
+			e.offset++
+			if e.current().Type == token.EOF {
+				return e.eofErr()
+			}
+			continue
+		}
+		break
+	}
+	return nil
+}
+
+// expr parses a sequence of terms joined by '+'/'-'.
+func (e *Interpreter) expr() (object.Object, error) {
+	left, err := e.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := e.current().Type
+		if op != token.PLUS && op != token.MINUS {
+			break
+		}
+		e.offset++
+		if e.current().Type == token.EOF {
+			return nil, e.eofErr()
+		}
+		right, err := e.term()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case left.Type() == object.NUMBER && right.Type() == object.NUMBER:
+			l := left.(*object.NumberObject).Value
+			r := right.(*object.NumberObject).Value
+			if op == token.PLUS {
+				left = object.Number(l + r)
+			} else {
+				left = object.Number(l - r)
+			}
+		case left.Type() == object.STRING && right.Type() == object.STRING:
+			if op != token.PLUS {
+				return nil, e.err(errors.ErrTypeMismatch, "the '-' operator is not supported for strings")
+			}
+			left = object.String(left.(*object.StringObject).Value + right.(*object.StringObject).Value)
+		default:
+			return nil, e.err(errors.ErrTypeMismatch, "type mismatch: %s %s %s", left.Type(), op, right.Type())
+		}
+	}
+	return left, nil
+}
+
+// term parses a sequence of factors joined by '*'/'/'/'%'/'^', all of
+// which only operate on numbers.
+func (e *Interpreter) term() (object.Object, error) {
+	left, err := e.factor()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := e.current().Type
+		if op != token.ASTERISK && op != token.SLASH && op != token.MOD && op != token.POW {
+			break
+		}
+		e.offset++
+		if e.current().Type == token.EOF {
+			return nil, e.eofErr()
+		}
+		right, err := e.factor()
+		if err != nil {
+			return nil, err
+		}
+
+		if left.Type() != object.NUMBER || right.Type() != object.NUMBER {
+			return nil, e.err(errors.ErrTypeMismatch, "term() only handles integers, not %s/%s", left.Type(), right.Type())
+		}
+		l := left.(*object.NumberObject).Value
+		r := right.(*object.NumberObject).Value
+
+		switch op {
+		case token.ASTERISK:
+			left = object.Number(l * r)
+		case token.SLASH:
+			if r == 0 {
+				return nil, e.err(errors.ErrRuntime, "division by zero")
+			}
+			left = object.Number(l / r)
+		case token.MOD:
+			if int(r) == 0 {
+				return nil, e.err(errors.ErrRuntime, "division by zero in '%%' operator")
+			}
+			left = object.Number(float64(int(l) % int(r)))
+		case token.POW:
+			p := 1.0
+			for n := 0; n < int(r); n++ {
+				p *= l
+			}
+			left = object.Number(p)
+		}
+	}
+	return left, nil
+}
+
+// factor parses the smallest unit of an expression: a literal, a
+// bracketed expression, a variable reference, a function call, or a
+// unary minus.
+func (e *Interpreter) factor() (object.Object, error) {
+	tok := e.current()
+
+	switch tok.Type {
+	case token.EOF:
+		return nil, e.eofErr()
+	case token.INT:
+		e.offset++
+		val, _ := strconv.ParseFloat(tok.Value, 64)
+		return object.Number(val), nil
+	case token.STRING:
+		e.offset++
+		return object.String(tok.Value), nil
+	case token.MINUS:
+		e.offset++
+		if e.current().Type == token.EOF {
+			return nil, e.eofErr()
+		}
+		val, err := e.factor()
+		if err != nil {
+			return nil, err
+		}
+		if val.Type() != object.NUMBER {
+			return nil, e.err(errors.ErrTypeMismatch, "unary minus only handles integers, not %s", val.Type())
+		}
+		return object.Number(-val.(*object.NumberObject).Value), nil
+	case token.LPAREN:
+		e.offset++
+		val, err := e.expr()
+		if err != nil {
+			return nil, err
+		}
+		switch e.current().Type {
+		case token.EOF:
+			return nil, e.eofErr()
+		case token.RPAREN:
+			e.offset++
+			return val, nil
+		default:
+			return nil, e.err(errors.ErrUnclosedBracket, "Unclosed bracket")
+		}
+	case token.IDENT:
+		name := tok.Value
+		e.offset++
+		if e.current().Type == token.LPAREN {
+			return e.callIdent(name)
+		}
+		val, ok := e.vars[name]
+		if !ok {
+			return nil, e.err(errors.ErrUndefinedIdent, "undefined variable '%s'", name)
+		}
+		return val, nil
+	default:
+		return nil, e.err(errors.ErrSyntax, "unexpected token %s in expression", tok.Type)
+	}
+}
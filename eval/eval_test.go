@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/skx/gobasic/errors"
 	"github.com/skx/gobasic/object"
 	"github.com/skx/gobasic/tokenizer"
 )
@@ -200,6 +201,9 @@ func TestMismatchedTypes(t *testing.T) {
 	if !strings.Contains(err.Error(), "type mismatch") {
 		t.Errorf("Our error-message wasn't what we expected")
 	}
+	if !errors.Is(err, errors.ErrTypeMismatch) {
+		t.Errorf("Expected an ErrTypeMismatch, got %v", err)
+	}
 }
 
 // TestMismatchedTypesTerm tests that term() errors on mismatched types.
@@ -243,6 +247,9 @@ func TestStringFail(t *testing.T) {
 	if !strings.Contains(err.Error(), "not supported for strings") {
 		t.Errorf("Our error-message wasn't what we expected")
 	}
+	if !errors.Is(err, errors.ErrTypeMismatch) {
+		t.Errorf("Expected an ErrTypeMismatch, got %v", err)
+	}
 }
 
 // TestExprTerm tests that expr() errors on unclosed brackets.
@@ -317,6 +324,9 @@ func TestEOF(t *testing.T) {
 		if !strings.Contains(err.Error(), "end of program") {
 			t.Errorf("Error '%s' wasn't an end-of-program error!", err.Error())
 		}
+		if !errors.Is(err, errors.ErrEndOfProgram) {
+			t.Errorf("Expected an ErrEndOfProgram for '%s', got %v", test, err)
+		}
 	}
 }
 
@@ -360,6 +370,48 @@ func TestMaths(t *testing.T) {
 	}
 }
 
+// TestReadAtEndOfProgram ensures a READ which is the final statement of
+// a program, with no trailing newline, succeeds rather than being
+// mistaken for a premature end-of-program.
+func TestReadAtEndOfProgram(t *testing.T) {
+	input := `10 DATA "Cat"
+20 READ a`
+
+	e, err := FromString(input)
+	if err != nil {
+		t.Fatalf("unexpected error creating interpreter: %s", err.Error())
+	}
+	if err = e.Run(); err != nil {
+		t.Fatalf("unexpected error running program: %s", err.Error())
+	}
+	if out := e.GetVariable("a").(*object.StringObject).Value; out != "Cat" {
+		t.Errorf("expected a to be %q, got %q", "Cat", out)
+	}
+}
+
+// TestDivideByZero ensures that '/' and '%' by zero are reported as a
+// typed error, rather than yielding +Inf or panicking the process.
+func TestDivideByZero(t *testing.T) {
+	tests := []string{
+		"10 LET a = 1 / 0",
+		"10 LET a = 5 % 0",
+		"10 LET a = 5 % 0.5",
+	}
+	for _, test := range tests {
+		e, err := FromString(test)
+		if err != nil {
+			t.Fatalf("unexpected error creating interpreter for %q: %s", test, err.Error())
+		}
+		err = e.Run()
+		if err == nil {
+			t.Fatalf("expected a division-by-zero error for %q, got none", test)
+		}
+		if !errors.Is(err, errors.ErrRuntime) {
+			t.Errorf("expected an ErrRuntime for %q, got %v", test, err)
+		}
+	}
+}
+
 // TestRead ensures that the READ statement is sane.
 func TestRead(t *testing.T) {
 
@@ -382,6 +434,9 @@ func TestRead(t *testing.T) {
 	if !strings.Contains(err.Error(), "Expected identifier") {
 		t.Errorf("Our error-message wasn't what we expected")
 	}
+	if !errors.Is(err, errors.ErrSyntax) {
+		t.Errorf("Expected an ErrSyntax, got %v", err)
+	}
 
 	//
 	// This will fail because we READ too far.
@@ -401,6 +456,9 @@ func TestRead(t *testing.T) {
 	if !strings.Contains(err.Error(), "Read past the end of our DATA storage") {
 		t.Errorf("Our error-message wasn't what we expected")
 	}
+	if !errors.Is(err, errors.ErrReadPastEnd) {
+		t.Errorf("Expected an ErrReadPastEnd, got %v", err)
+	}
 
 	//
 	// Now a working example.
@@ -447,3 +505,300 @@ func TestRead(t *testing.T) {
 	}
 
 }
+
+// TestRestore ensures that RESTORE rewinds the DATA read-cursor, both
+// to the start and to a specific DATA line, via both the BASIC
+// statement and the Go API.
+func TestRestore(t *testing.T) {
+
+	//
+	// READ everything, RESTORE, then READ the same values again.
+	//
+	again := `
+10 DATA "Cat", "Dog"
+20 READ a
+30 READ b
+40 RESTORE
+50 READ c
+60 READ d
+`
+	e, err := FromString(again)
+	if err != nil {
+		t.Errorf("Error parsing %s - %s", again, err.Error())
+	}
+	err = e.Run()
+	if err != nil {
+		t.Errorf("Expected no error, but found one: %s", err.Error())
+	}
+	for _, pair := range [][2]string{{"a", "c"}, {"b", "d"}} {
+		first := e.GetVariable(pair[0]).(*object.StringObject).Value
+		second := e.GetVariable(pair[1]).(*object.StringObject).Value
+		if first != second {
+			t.Errorf("Expected %s to equal %s after RESTORE, got %s vs %s", pair[0], pair[1], first, second)
+		}
+	}
+
+	//
+	// RESTORE to a specific line, then READ from that point.
+	//
+	toLine := `
+10 DATA "first"
+20 DATA "second"
+30 READ a
+40 RESTORE 20
+50 READ b
+`
+	e, err = FromString(toLine)
+	if err != nil {
+		t.Errorf("Error parsing %s - %s", toLine, err.Error())
+	}
+	err = e.Run()
+	if err != nil {
+		t.Errorf("Expected no error, but found one: %s", err.Error())
+	}
+	if out := e.GetVariable("a").(*object.StringObject).Value; out != "first" {
+		t.Errorf("Expected a to be %q, got %q", "first", out)
+	}
+	if out := e.GetVariable("b").(*object.StringObject).Value; out != "second" {
+		t.Errorf("Expected b to be %q, got %q", "second", out)
+	}
+
+	//
+	// RESTORE to a non-existent line is a typed error, whether reached
+	// via the BASIC statement or the Go API.
+	//
+	bogus := `
+10 DATA "only"
+20 RESTORE 99
+`
+	e, err = FromString(bogus)
+	if err != nil {
+		t.Errorf("Error parsing %s - %s", bogus, err.Error())
+	}
+	err = e.Run()
+	if err == nil {
+		t.Errorf("Expected to see an error, but didn't.")
+	}
+	if !errors.Is(err, errors.ErrUndefinedIdent) {
+		t.Errorf("Expected an ErrUndefinedIdent, got %v", err)
+	}
+
+	e, err = FromString(`10 DATA "only"`)
+	if err != nil {
+		t.Errorf("Error parsing - %s", err.Error())
+	}
+	if err := e.RestoreData(99); err == nil {
+		t.Errorf("Expected RestoreData to a non-existent line to fail")
+	} else if !errors.Is(err, errors.ErrUndefinedIdent) {
+		t.Errorf("Expected an ErrUndefinedIdent, got %v", err)
+	}
+}
+
+// doubleFunction is a trivial Callable used by TestRegisterCallable and
+// TestRegisterCallableTypeMismatch.
+type doubleFunction struct{}
+
+func (d *doubleFunction) Name() string                { return "DOUBLE" }
+func (d *doubleFunction) Params() []object.ObjectType { return []object.ObjectType{object.NUMBER} }
+func (d *doubleFunction) Ret() object.ObjectType      { return object.NUMBER }
+func (d *doubleFunction) Call(e *Interpreter, args []object.Object) object.Object {
+	n := args[0].(*object.NumberObject).Value
+	return object.Number(n * 2)
+}
+
+// TestRegisterCallable ensures a host-registered function can be
+// invoked from a BASIC program, and that DeregisterAll removes it
+// again.
+func TestRegisterCallable(t *testing.T) {
+	input := `10 LET a = DOUBLE(21)`
+
+	e, err := FromString(input)
+	if err != nil {
+		t.Fatalf("unexpected error creating interpreter: %s", err.Error())
+	}
+	e.Register(&doubleFunction{})
+
+	if err = e.Run(); err != nil {
+		t.Fatalf("unexpected error running program: %s", err.Error())
+	}
+
+	out := e.GetVariable("a")
+	if out.Type() != object.NUMBER {
+		t.Fatalf("variable 'a' had the wrong type: %s", out.Type())
+	}
+	if out.(*object.NumberObject).Value != 42 {
+		t.Fatalf("expected 42, got %v", out.(*object.NumberObject).Value)
+	}
+
+	e2, err := FromString(input)
+	if err != nil {
+		t.Fatalf("unexpected error creating interpreter: %s", err.Error())
+	}
+	e2.Register(&doubleFunction{})
+	e2.DeregisterAll()
+
+	if err = e2.Run(); err == nil {
+		t.Fatalf("expected an error calling a deregistered function, got none")
+	}
+}
+
+// TestRegisterCallableTypeMismatch ensures a type-mismatched argument
+// to a registered function produces an error naming both the expected
+// and the actual type.
+func TestRegisterCallableTypeMismatch(t *testing.T) {
+	input := `10 LET a = DOUBLE("steve")`
+
+	e, err := FromString(input)
+	if err != nil {
+		t.Fatalf("unexpected error creating interpreter: %s", err.Error())
+	}
+	e.Register(&doubleFunction{})
+
+	err = e.Run()
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if !strings.Contains(err.Error(), string(object.NUMBER)) || !strings.Contains(err.Error(), string(object.STRING)) {
+		t.Fatalf("error %q did not mention both the expected and actual types", err.Error())
+	}
+}
+
+// runNumberVar runs the given program and returns the numeric value of
+// the named variable, failing the test if either step goes wrong.
+func runNumberVar(t *testing.T, input, name string) float64 {
+	t.Helper()
+
+	e, err := FromString(input)
+	if err != nil {
+		t.Fatalf("unexpected error creating interpreter: %s", err.Error())
+	}
+	if err = e.Run(); err != nil {
+		t.Fatalf("unexpected error running program: %s", err.Error())
+	}
+
+	out := e.GetVariable(name)
+	if out.Type() != object.NUMBER {
+		t.Fatalf("variable '%s' had the wrong type: %s", name, out.Type())
+	}
+	return out.(*object.NumberObject).Value
+}
+
+// TestDefFn ensures a simple expression-valued DEF FN can be defined
+// and invoked.
+func TestDefFn(t *testing.T) {
+	input := `10 DEF FN SQUARE(x) = x * x
+20 LET a = SQUARE(7)
+`
+	if got := runNumberVar(t, input, "a"); got != 49 {
+		t.Errorf("expected 49, got %v", got)
+	}
+}
+
+// TestSubRecursion calls a recursive SUB to compute a factorial.
+func TestSubRecursion(t *testing.T) {
+	input := `100 SUB FACT(n)
+110 IF n <= 1 THEN RETURN 1
+120 RETURN n * FACT(n-1)
+130 END SUB
+200 LET a = FACT(5)
+`
+	if got := runNumberVar(t, input, "a"); got != 120 {
+		t.Errorf("expected 120, got %v", got)
+	}
+}
+
+// TestSubMutualRecursion calls two SUBs which recurse into each other.
+func TestSubMutualRecursion(t *testing.T) {
+	input := `100 SUB ISEVEN(n)
+110 IF n = 0 THEN RETURN 1
+120 RETURN ISODD(n-1)
+130 END SUB
+200 SUB ISODD(n)
+210 IF n = 0 THEN RETURN 0
+220 RETURN ISEVEN(n-1)
+230 END SUB
+300 LET a = ISEVEN(10)
+310 LET b = ISODD(10)
+`
+	if got := runNumberVar(t, input, "a"); got != 1 {
+		t.Errorf("expected ISEVEN(10) to be 1, got %v", got)
+	}
+	if got := runNumberVar(t, input, "b"); got != 0 {
+		t.Errorf("expected ISODD(10) to be 0, got %v", got)
+	}
+}
+
+// TestSubGosub ensures a GOSUB issued from within a SUB's body is
+// popped by its own RETURN, rather than being mistaken for the RETURN
+// that ends the call.
+func TestSubGosub(t *testing.T) {
+	input := `100 SUB DOUBLE(n)
+110 GOSUB 500
+120 RETURN n * 2
+130 END SUB
+140 LET a = DOUBLE(5)
+150 GOTO 900
+500 LET helper = 1
+510 RETURN
+900 LET b = helper
+`
+	if got := runNumberVar(t, input, "a"); got != 10 {
+		t.Errorf("expected DOUBLE(5) to be 10, got %v", got)
+	}
+	if got := runNumberVar(t, input, "b"); got != 1 {
+		t.Errorf("expected the GOSUB inside the SUB to have run, got %v", got)
+	}
+}
+
+// TestFnParamShadowsGlobal ensures a parameter shadows a global
+// variable of the same name for the duration of the call, and that
+// the global is restored once the call returns.
+func TestFnParamShadowsGlobal(t *testing.T) {
+	input := `10 LET x = 100
+20 DEF FN DOUBLE(x) = x * 2
+30 LET before = x
+40 LET a = DOUBLE(5)
+50 LET after = x
+`
+	if got := runNumberVar(t, input, "before"); got != 100 {
+		t.Errorf("expected global x to be 100 before the call, got %v", got)
+	}
+	if got := runNumberVar(t, input, "a"); got != 10 {
+		t.Errorf("expected DOUBLE(5) to be 10, got %v", got)
+	}
+	if got := runNumberVar(t, input, "after"); got != 100 {
+		t.Errorf("expected global x to be restored to 100 after the call, got %v", got)
+	}
+}
+
+// TestFnArityMismatch ensures calling a DEF FN with the wrong number of
+// arguments is reported as an error.
+func TestFnArityMismatch(t *testing.T) {
+	input := `10 DEF FN SQUARE(x) = x * x
+20 LET a = SQUARE(1, 2)
+`
+	e, err := FromString(input)
+	if err != nil {
+		t.Fatalf("unexpected error creating interpreter: %s", err.Error())
+	}
+	if err = e.Run(); err == nil {
+		t.Fatalf("expected an arity error, got none")
+	}
+}
+
+// TestFnUndefined ensures calling an undefined FN/SUB is reported as
+// an error, rather than silently returning a zero value.
+func TestFnUndefined(t *testing.T) {
+	input := `10 LET a = NOSUCHFUNCTION(1)`
+
+	e, err := FromString(input)
+	if err != nil {
+		t.Fatalf("unexpected error creating interpreter: %s", err.Error())
+	}
+	if err = e.Run(); err == nil {
+		t.Fatalf("expected an error calling an undefined function, got none")
+	}
+	if !strings.Contains(err.Error(), "NOSUCHFUNCTION") {
+		t.Errorf("error %q did not name the undefined function", err.Error())
+	}
+}
@@ -0,0 +1,323 @@
+// userfunc.go - Support for user-defined routines written in BASIC
+// itself: expression-valued `DEF FN NAME(params) = expr` functions, and
+// procedural `SUB NAME(params) ... RETURN expr ... END SUB` routines.
+// Both are callable as `NAME(args...)` from any expression context,
+// alongside host-registered Callables (see callable.go).
+package eval
+
+import (
+	"strconv"
+
+	"github.com/skx/gobasic/errors"
+	"github.com/skx/gobasic/object"
+	"github.com/skx/gobasic/token"
+)
+
+// maxCallDepth bounds the recursion depth of DEF FN/SUB calls, so that
+// a pathological (or fuzzed) program can't blow the Go stack.
+const maxCallDepth = 255
+
+// userFunc describes a single user-defined routine, collected from the
+// source during New().
+type userFunc struct {
+	// name is the identifier the routine is called by.
+	name string
+
+	// params holds the name of each formal parameter, in order.
+	params []string
+
+	// isSub is true for a `SUB ... END SUB` routine, false for a
+	// `DEF FN ... = expr` one.
+	isSub bool
+
+	// body is the token-offset of the expression (for a DEF FN) or
+	// of the first statement of the body (for a SUB).
+	body int
+}
+
+// returnSignal is a sentinel error used to unwind the statement
+// execution started by callUserFunction when a `RETURN value` is
+// reached, however deeply it is nested (e.g. inside an IF). It is
+// caught by callUserFunction, never surfaced to the caller as a real
+// error.
+type returnSignal struct {
+	value object.Object
+}
+
+// Error satisfies the error interface; it should never actually be
+// seen, since callUserFunction always intercepts a *returnSignal.
+func (r *returnSignal) Error() string {
+	return "RETURN outside of a DEF FN/SUB call"
+}
+
+// collectUserFuncs walks every token in the program looking for DEF FN
+// and SUB definitions, recording each in e.userFuncs. It runs once,
+// up-front, so that a function may be called before its definition is
+// reached, and so that malformed definitions are reported before the
+// program ever runs.
+func (e *Interpreter) collectUserFuncs() error {
+	i := 0
+	for i < len(e.tokens) {
+		switch e.tokens[i].Type {
+		case token.DEF:
+			next, err := e.collectDefFn(i)
+			if err != nil {
+				return err
+			}
+			i = next
+		case token.SUB:
+			next, err := e.collectSub(i)
+			if err != nil {
+				return err
+			}
+			i = next
+		default:
+			i++
+		}
+	}
+	return nil
+}
+
+// locOf returns the location of the token at index i, or of the last
+// token in tokens if i runs off the end - used for errors found during
+// the pre-pass collection of DEF FN/SUB, before e.curLine has any
+// meaning. Only the column is filled in; Line is left 0, as the BASIC
+// line-number is not tracked during that pass.
+func locOf(tokens []token.Token, i int) errors.Location {
+	if i >= len(tokens) {
+		i = len(tokens) - 1
+	}
+	return errors.Location{Column: tokens[i].Location.Column}
+}
+
+// parseParamList parses a parenthesized, comma-separated list of
+// parameter names starting at index i, which must be a '('. It returns
+// the parameter names, and the index of the token following the ')'.
+func parseParamList(tokens []token.Token, i int) ([]string, int, error) {
+	if i >= len(tokens) || tokens[i].Type != token.LPAREN {
+		return nil, i, errors.New(errors.ErrSyntax, locOf(tokens, i), "expected '(' to begin a parameter list")
+	}
+	i++
+
+	var params []string
+	for i < len(tokens) && tokens[i].Type != token.RPAREN {
+		if tokens[i].Type != token.IDENT {
+			return nil, i, errors.New(errors.ErrSyntax, locOf(tokens, i), "expected a parameter name, got %s", tokens[i].Type)
+		}
+		params = append(params, tokens[i].Value)
+		i++
+
+		if i < len(tokens) && tokens[i].Type == token.COMMA {
+			i++
+		}
+	}
+	if i >= len(tokens) || tokens[i].Type != token.RPAREN {
+		return nil, i, errors.New(errors.ErrSyntax, locOf(tokens, i), "unterminated parameter list")
+	}
+	return params, i + 1, nil
+}
+
+// collectDefFn parses a single `DEF FN NAME(params) = expr`, starting
+// at the index of the DEF token, and returns the index of the token
+// following it.
+func (e *Interpreter) collectDefFn(i int) (int, error) {
+	i++
+	if i >= len(e.tokens) || e.tokens[i].Type != token.FN {
+		return i, errors.New(errors.ErrSyntax, locOf(e.tokens, i), "expected FN after DEF")
+	}
+	i++
+	if i >= len(e.tokens) || e.tokens[i].Type != token.IDENT {
+		return i, errors.New(errors.ErrSyntax, locOf(e.tokens, i), "expected a function name after DEF FN")
+	}
+	name := e.tokens[i].Value
+	i++
+
+	params, i, err := parseParamList(e.tokens, i)
+	if err != nil {
+		return i, errors.New(errors.ErrSyntax, locOf(e.tokens, i), "DEF FN %s: %s", name, err)
+	}
+
+	if i >= len(e.tokens) || e.tokens[i].Type != token.ASSIGN {
+		return i, errors.New(errors.ErrSyntax, locOf(e.tokens, i), "DEF FN %s: expected '=' before its expression", name)
+	}
+	i++
+
+	e.userFuncs[name] = &userFunc{name: name, params: params, body: i}
+
+	for i < len(e.tokens) && e.tokens[i].Type != token.NEWLINE && e.tokens[i].Type != token.EOF {
+		i++
+	}
+	return i, nil
+}
+
+// collectSub parses a single `SUB NAME(params) ... END SUB`, starting
+// at the index of the SUB token, and returns the index of the token
+// following it.
+func (e *Interpreter) collectSub(i int) (int, error) {
+	i++
+	if i >= len(e.tokens) || e.tokens[i].Type != token.IDENT {
+		return i, errors.New(errors.ErrSyntax, locOf(e.tokens, i), "expected a routine name after SUB")
+	}
+	name := e.tokens[i].Value
+	i++
+
+	params, i, err := parseParamList(e.tokens, i)
+	if err != nil {
+		return i, errors.New(errors.ErrSyntax, locOf(e.tokens, i), "SUB %s: %s", name, err)
+	}
+
+	bodyStart := i
+	for i < len(e.tokens) {
+		if e.tokens[i].Type == token.END && i+1 < len(e.tokens) && e.tokens[i+1].Type == token.SUB {
+			break
+		}
+		i++
+	}
+	if i >= len(e.tokens) {
+		return i, errors.New(errors.ErrSyntax, locOf(e.tokens, i), "SUB %s: missing END SUB", name)
+	}
+
+	e.userFuncs[name] = &userFunc{name: name, params: params, isSub: true, body: bodyStart}
+	return i + 2, nil
+}
+
+// skipSub discards the tokens of a SUB's definition at run-time - its
+// body was already recorded by collectSub, and must not be executed
+// in sequence.
+func (e *Interpreter) skipSub() {
+	e.offset++ // consume the opening SUB token
+	for {
+		switch e.current().Type {
+		case token.EOF:
+			return
+		case token.END:
+			e.offset++
+			if e.current().Type == token.SUB {
+				e.offset++
+			}
+			return
+		default:
+			e.offset++
+		}
+	}
+}
+
+// callIdent dispatches a call to the function or routine called name,
+// whose next token is the opening '(' of its argument list. Host
+// functions registered via Register take priority over BASIC-defined
+// ones of the same name.
+func (e *Interpreter) callIdent(name string) (object.Object, error) {
+	if c, ok := e.callables[name]; ok {
+		return e.callHostFunction(name, c)
+	}
+	if uf, ok := e.userFuncs[name]; ok {
+		return e.callUserFunction(uf)
+	}
+	return nil, e.err(errors.ErrUndefinedIdent, "call to undefined function %s()", name)
+}
+
+// callUserFunction evaluates a call to a DEF FN or SUB routine: it
+// parses the argument list, binds parameters over any globals of the
+// same name, runs the body, and restores the shadowed globals
+// afterwards - however the call terminates.
+func (e *Interpreter) callUserFunction(uf *userFunc) (object.Object, error) {
+	if e.callDepth >= maxCallDepth {
+		return nil, e.err(errors.ErrRuntime, "%s() exceeded the maximum call depth of %d", uf.name, maxCallDepth)
+	}
+
+	args, err := e.parseCallArgs()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != len(uf.params) {
+		return nil, e.err(errors.ErrArity, "%s() expects %d argument(s), got %d", uf.name, len(uf.params), len(args))
+	}
+
+	shadowed := e.bindParams(uf.params, args)
+	defer e.unbindParams(shadowed)
+
+	savedOffset := e.offset
+	e.offset = uf.body
+	e.callDepth++
+	e.gosubBase = append(e.gosubBase, len(e.gosubStack))
+
+	var result object.Object
+	if uf.isSub {
+		result, err = e.runBody(uf.name)
+	} else {
+		result, err = e.expr()
+	}
+
+	e.gosubBase = e.gosubBase[:len(e.gosubBase)-1]
+	e.callDepth--
+	e.offset = savedOffset
+
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// shadowedVar records the prior value of a variable a call has bound a
+// parameter over, so it can be restored once the call returns.
+type shadowedVar struct {
+	name    string
+	existed bool
+	value   object.Object
+}
+
+// bindParams binds each parameter name to its argument, returning
+// enough information to undo the binding once the call completes.
+func (e *Interpreter) bindParams(params []string, args []object.Object) []shadowedVar {
+	shadowed := make([]shadowedVar, len(params))
+	for i, name := range params {
+		old, existed := e.vars[name]
+		shadowed[i] = shadowedVar{name: name, existed: existed, value: old}
+		e.vars[name] = args[i]
+	}
+	return shadowed
+}
+
+// unbindParams restores the variables bindParams shadowed.
+func (e *Interpreter) unbindParams(shadowed []shadowedVar) {
+	for _, s := range shadowed {
+		if s.existed {
+			e.vars[s.name] = s.value
+		} else {
+			delete(e.vars, s.name)
+		}
+	}
+}
+
+// runBody executes statements, starting at the current offset, until a
+// `RETURN value` is reached (whose value is returned), or the body
+// falls off the end of its SUB without one (which is an error).
+func (e *Interpreter) runBody(name string) (object.Object, error) {
+	for {
+		tok := e.current()
+		switch tok.Type {
+		case token.EOF:
+			return nil, e.err(errors.ErrRuntime, "%s() fell off the end without a RETURN", name)
+		case token.NEWLINE:
+			e.offset++
+		case token.INT:
+			if n, err := strconv.Atoi(tok.Value); err == nil {
+				e.curLine = n
+			}
+			e.offset++
+		case token.END:
+			e.offset++
+			if e.current().Type == token.SUB {
+				e.offset++
+			}
+			return nil, e.err(errors.ErrRuntime, "%s() fell off the end without a RETURN", name)
+		default:
+			if err := e.runStatement(); err != nil {
+				if rs, ok := err.(*returnSignal); ok {
+					return rs.value, nil
+				}
+				return nil, err
+			}
+		}
+	}
+}
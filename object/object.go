@@ -0,0 +1,79 @@
+// Package object contains the definition of the values our evaluator
+// operates upon: numbers, strings, and errors.
+package object
+
+import "fmt"
+
+// ObjectType describes the type of an Object.
+type ObjectType string
+
+// The types of object we support.
+const (
+	NUMBER ObjectType = "NUMBER"
+	STRING ObjectType = "STRING"
+	ERROR  ObjectType = "ERROR"
+)
+
+// Object is the interface implemented by every value our evaluator
+// can hold in a variable, pass as a function argument, or produce
+// as the result of an expression.
+type Object interface {
+	// Type returns the type of this object.
+	Type() ObjectType
+
+	// String returns a string representation of this object.
+	String() string
+}
+
+// NumberObject wraps a numeric value.
+type NumberObject struct {
+	// Value is the actual number.
+	Value float64
+}
+
+// Type returns the type of this object.
+func (n *NumberObject) Type() ObjectType { return NUMBER }
+
+// String returns a string representation of this object.
+func (n *NumberObject) String() string { return fmt.Sprintf("%v", n.Value) }
+
+// StringObject wraps a string value.
+type StringObject struct {
+	// Value is the actual string.
+	Value string
+}
+
+// Type returns the type of this object.
+func (s *StringObject) Type() ObjectType { return STRING }
+
+// String returns a string representation of this object.
+func (s *StringObject) String() string { return s.Value }
+
+// ErrorObject wraps an error, which is used both to report failures
+// to the user and to signal a missing variable from GetVariable.
+type ErrorObject struct {
+	// Value is the human-readable error message.
+	Value string
+}
+
+// Type returns the type of this object.
+func (e *ErrorObject) Type() ObjectType { return ERROR }
+
+// String returns a string representation of this object.
+func (e *ErrorObject) String() string { return e.Value }
+
+// Number is a helper for constructing a NumberObject.
+func Number(val float64) Object {
+	return &NumberObject{Value: val}
+}
+
+// String is a helper for constructing a StringObject.
+func String(val string) Object {
+	return &StringObject{Value: val}
+}
+
+// Error is a helper for constructing an ErrorObject, with printf-style
+// formatting of the message.
+func Error(fm string, args ...interface{}) Object {
+	return &ErrorObject{Value: fmt.Sprintf(fm, args...)}
+}
@@ -0,0 +1,118 @@
+// Package token contains the definitions of the tokens our tokenizer
+// produces, and which our evaluator subsequently consumes.
+package token
+
+// Type describes the type of a token.
+type Type string
+
+// Location records where, in the source, a token was read from.
+type Location struct {
+	// Line is the physical source line the token starts on (1-based).
+	Line int
+
+	// Column is the character offset of the token within Line
+	// (1-based).
+	Column int
+}
+
+// Token is the structure produced by the tokenizer, and consumed by
+// the evaluator.
+type Token struct {
+	// Type is the type of the token.
+	Type Type
+
+	// Value is the literal value of the token, as read from the
+	// input program.
+	Value string
+
+	// Location is where in the source this token was read from.
+	Location Location
+}
+
+// pre-defined Type values.
+const (
+	EOF     = "EOF"
+	NEWLINE = "NEWLINE"
+	IDENT   = "IDENT"
+	INT     = "INT"
+	STRING  = "STRING"
+
+	// operators
+	ASSIGN   = "="
+	PLUS     = "+"
+	MINUS    = "-"
+	ASTERISK = "*"
+	SLASH    = "/"
+	POW      = "^"
+	MOD      = "%"
+
+	// comparisons
+	LT = "<"
+	GT = ">"
+	LE = "<="
+	GE = ">="
+	EQ = "=="
+	NE = "<>"
+
+	// delimiters
+	LPAREN = "("
+	RPAREN = ")"
+	COMMA  = ","
+
+	// keywords
+	LET     = "LET"
+	PRINT   = "PRINT"
+	INPUT   = "INPUT"
+	IF      = "IF"
+	THEN    = "THEN"
+	ELSE    = "ELSE"
+	GOTO    = "GOTO"
+	GOSUB   = "GOSUB"
+	RETURN  = "RETURN"
+	FOR     = "FOR"
+	TO      = "TO"
+	STEP    = "STEP"
+	NEXT    = "NEXT"
+	DATA    = "DATA"
+	READ    = "READ"
+	RESTORE = "RESTORE"
+	REM     = "REM"
+	DEF     = "DEF"
+	FN      = "FN"
+	SUB     = "SUB"
+	END     = "END"
+)
+
+// keywords maps the textual representation of a keyword to its Type.
+var keywords = map[string]Type{
+	"LET":     LET,
+	"PRINT":   PRINT,
+	"INPUT":   INPUT,
+	"IF":      IF,
+	"THEN":    THEN,
+	"ELSE":    ELSE,
+	"GOTO":    GOTO,
+	"GOSUB":   GOSUB,
+	"RETURN":  RETURN,
+	"FOR":     FOR,
+	"TO":      TO,
+	"STEP":    STEP,
+	"NEXT":    NEXT,
+	"DATA":    DATA,
+	"READ":    READ,
+	"RESTORE": RESTORE,
+	"REM":     REM,
+	"DEF":     DEF,
+	"FN":      FN,
+	"SUB":     SUB,
+	"END":     END,
+}
+
+// LookupIdentifier returns the Type for the given identifier, returning
+// IDENT if the given string isn't a reserved keyword.
+func LookupIdentifier(id string) Type {
+	if tok, ok := keywords[id]; ok {
+		return tok
+	}
+	return IDENT
+}
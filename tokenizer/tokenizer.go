@@ -0,0 +1,210 @@
+// Package tokenizer contains a simple tokenizer for reading a BASIC
+// program and converting it into a series of tokens, for consumption
+// by our evaluator.
+package tokenizer
+
+import (
+	"strings"
+
+	"github.com/skx/gobasic/token"
+)
+
+// Tokenizer holds our state.
+type Tokenizer struct {
+	// input is the string we're lexing.
+	input string
+
+	// position is the current offset into the input.
+	position int
+
+	// readPosition is the next offset into the input.
+	readPosition int
+
+	// ch is the current character under examination.
+	ch byte
+
+	// line is the source line t.ch was read from (1-based).
+	line int
+
+	// col is the column, within line, t.ch was read from (1-based).
+	col int
+}
+
+// New creates a new Tokenizer, given the program to tokenize.
+func New(input string) *Tokenizer {
+	t := &Tokenizer{input: input, line: 1}
+	t.readChar()
+	return t
+}
+
+// readChar reads the next character, advancing our internal state -
+// including the line/column our Next() attaches to every token.
+func (t *Tokenizer) readChar() {
+	if t.ch == '\n' {
+		t.line++
+		t.col = 0
+	}
+	if t.readPosition >= len(t.input) {
+		t.ch = 0
+	} else {
+		t.ch = t.input[t.readPosition]
+	}
+	t.position = t.readPosition
+	t.readPosition++
+	t.col++
+}
+
+// peekChar returns the next character, without advancing our state.
+func (t *Tokenizer) peekChar() byte {
+	if t.readPosition >= len(t.input) {
+		return 0
+	}
+	return t.input[t.readPosition]
+}
+
+// skipWhitespace consumes any pending space or tab - but not a newline,
+// which is significant as it separates BASIC statements.
+func (t *Tokenizer) skipWhitespace() {
+	for t.ch == ' ' || t.ch == '\t' || t.ch == '\r' {
+		t.readChar()
+	}
+}
+
+// Next returns the next token found in our input, or a token of
+// type token.EOF once the input has been consumed.
+func (t *Tokenizer) Next() token.Token {
+	var tok token.Token
+
+	t.skipWhitespace()
+
+	loc := token.Location{Line: t.line, Column: t.col}
+
+	switch t.ch {
+	case '\n':
+		tok = token.Token{Type: token.NEWLINE, Value: "\n"}
+	case '=':
+		if t.peekChar() == '=' {
+			t.readChar()
+			tok = token.Token{Type: token.EQ, Value: "=="}
+		} else {
+			tok = token.Token{Type: token.ASSIGN, Value: "="}
+		}
+	case '+':
+		tok = token.Token{Type: token.PLUS, Value: "+"}
+	case '-':
+		tok = token.Token{Type: token.MINUS, Value: "-"}
+	case '*':
+		tok = token.Token{Type: token.ASTERISK, Value: "*"}
+	case '/':
+		tok = token.Token{Type: token.SLASH, Value: "/"}
+	case '^':
+		tok = token.Token{Type: token.POW, Value: "^"}
+	case '%':
+		tok = token.Token{Type: token.MOD, Value: "%"}
+	case '(':
+		tok = token.Token{Type: token.LPAREN, Value: "("}
+	case ')':
+		tok = token.Token{Type: token.RPAREN, Value: ")"}
+	case ',':
+		tok = token.Token{Type: token.COMMA, Value: ","}
+	case '<':
+		if t.peekChar() == '=' {
+			t.readChar()
+			tok = token.Token{Type: token.LE, Value: "<="}
+		} else if t.peekChar() == '>' {
+			t.readChar()
+			tok = token.Token{Type: token.NE, Value: "<>"}
+		} else {
+			tok = token.Token{Type: token.LT, Value: "<"}
+		}
+	case '>':
+		if t.peekChar() == '=' {
+			t.readChar()
+			tok = token.Token{Type: token.GE, Value: ">="}
+		} else {
+			tok = token.Token{Type: token.GT, Value: ">"}
+		}
+	case '"':
+		tok = token.Token{Type: token.STRING, Value: t.readString()}
+	case 0:
+		tok = token.Token{Type: token.EOF, Value: ""}
+	default:
+		if isDigit(t.ch) {
+			return token.Token{Type: token.INT, Value: t.readNumber(), Location: loc}
+		}
+		if isLetter(t.ch) {
+			id := t.readIdentifier()
+			return token.Token{Type: token.LookupIdentifier(strings.ToUpper(id)), Value: id, Location: loc}
+		}
+		tok = token.Token{Type: token.EOF, Value: string(t.ch)}
+	}
+
+	t.readChar()
+	tok.Location = loc
+	return tok
+}
+
+// readIdentifier reads an identifier, which might also turn out to be
+// a reserved keyword.
+func (t *Tokenizer) readIdentifier() string {
+	pos := t.position
+	for isLetter(t.ch) || isDigit(t.ch) {
+		t.readChar()
+	}
+	return t.input[pos:t.position]
+}
+
+// readNumber reads an integer or floating-point literal.
+func (t *Tokenizer) readNumber() string {
+	pos := t.position
+	for isDigit(t.ch) {
+		t.readChar()
+	}
+	if t.ch == '.' && isDigit(t.peekChar()) {
+		t.readChar()
+		for isDigit(t.ch) {
+			t.readChar()
+		}
+	}
+	return t.input[pos:t.position]
+}
+
+// readString reads a string literal, interpreting a handful of the
+// usual backslash escapes.
+func (t *Tokenizer) readString() string {
+	var out strings.Builder
+
+	for {
+		t.readChar()
+		if t.ch == '"' || t.ch == 0 {
+			break
+		}
+		if t.ch == '\\' {
+			switch t.peekChar() {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case '"':
+				out.WriteByte('"')
+			case '\\':
+				out.WriteByte('\\')
+			default:
+				out.WriteByte(t.ch)
+				out.WriteByte(t.peekChar())
+			}
+			t.readChar()
+			continue
+		}
+		out.WriteByte(t.ch)
+	}
+	return out.String()
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isLetter(ch byte) bool {
+	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_' || ch == '$'
+}